@@ -3,11 +3,18 @@ package step
 import (
 	"bufio"
 	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -15,11 +22,11 @@ import (
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v2"
 
+	vmlog "github.com/koderover/zadig/v2/pkg/cli/zadig-agent/helper/log"
 	"github.com/koderover/zadig/v2/pkg/microservice/reaper/config"
 	"github.com/koderover/zadig/v2/pkg/setting"
 	"github.com/koderover/zadig/v2/pkg/tool/httpclient"
 	"github.com/koderover/zadig/v2/pkg/tool/log"
-	s3tool "github.com/koderover/zadig/v2/pkg/tool/s3"
 	"github.com/koderover/zadig/v2/pkg/types/step"
 )
 
@@ -28,10 +35,13 @@ type ToolInstallStep struct {
 	envs       []string
 	secretEnvs []string
 	workspace  string
+	// logger, when set, routes install output through the VM/agent
+	// structured log stream instead of raw stdout.
+	logger *vmlog.JobLogger
 }
 
-func NewToolInstallStep(spec interface{}, workspace string, envs, secretEnvs []string) (*ToolInstallStep, error) {
-	toolInstallStep := &ToolInstallStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+func NewToolInstallStep(spec interface{}, workspace string, envs, secretEnvs []string, logger *vmlog.JobLogger) (*ToolInstallStep, error) {
+	toolInstallStep := &ToolInstallStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs, logger: logger}
 	yamlBytes, err := yaml.Marshal(spec)
 	if err != nil {
 		return toolInstallStep, fmt.Errorf("marshal spec %+v failed", spec)
@@ -44,22 +54,45 @@ func NewToolInstallStep(spec interface{}, workspace string, envs, secretEnvs []s
 
 func (s *ToolInstallStep) Run(ctx context.Context) error {
 	start := time.Now()
-	log.Infof("Installing tools.")
+	s.infof("Installing tools.")
 	defer func() {
-		log.Infof("Install tools ended. Duration: %.2f seconds.", time.Since(start).Seconds())
+		s.infof("Install tools ended. Duration: %.2f seconds.", time.Since(start).Seconds())
 	}()
 
 	for _, tool := range s.spec.Installs {
-		log.Infof("Installing %s %s.", tool.Name, tool.Version)
-		if err := s.runIntallationScripts(tool); err != nil {
+		toolStart := time.Now()
+		s.infof("Installing %s %s.", tool.Name, tool.Version)
+		if err := s.runIntallationScripts(ctx, tool); err != nil {
 			return err
 		}
+		s.infof("Installed %s %s. Duration: %.2f seconds.", tool.Name, tool.Version, time.Since(toolStart).Seconds())
 	}
 
 	return nil
 }
 
-func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
+// infof and errorf forward to the structured JobLogger when one is
+// configured, falling back to the package-level logger otherwise.
+// vmlog.JobLogger.Infof/Errorf take ...interface{} and join them with
+// fmt.Sprint rather than treating the first argument as a format string,
+// so the message must be formatted before it is handed to the logger.
+func (s *ToolInstallStep) infof(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Infof(fmt.Sprintf(format, args...))
+		return
+	}
+	log.Infof(format, args...)
+}
+
+func (s *ToolInstallStep) errorf(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Errorf(fmt.Sprintf(format, args...))
+		return
+	}
+	log.Errorf(format, args...)
+}
+
+func (s *ToolInstallStep) runIntallationScripts(ctx context.Context, tool *step.Tool) error {
 	if tool == nil {
 		return nil
 	}
@@ -68,56 +101,41 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 		proxyScript, disProxyScript string
 	)
 
+	shell, err := resolveShell(tool)
+	if err != nil {
+		return fmt.Errorf("resolve shell for %s %s: %v", tool.Name, tool.Version, err)
+	}
+
 	var tmpPath string
 	scripts := []string{}
-	scripts = append(scripts, "set -ex")
+	scripts = append(scripts, shell.header)
 
 	// 获取用户指定环境变量
-	s.envs = append(s.envs, Environs(tool.Envs)...)
+	s.envs = append(s.envs, environsForShell(tool.Envs, shell)...)
 
 	if openProxy {
 		scripts = append(scripts, proxyScript)
 	}
 
-	// 如果应用有配置下载路径
-	if tool.Download != "" {
-		s.spec.S3Storage.Subfolder = fmt.Sprintf("%s/%s-v%s", config.ConstructCachePath, tool.Name, tool.Version)
-		filepath := strings.Split(tool.Download, "/")
-		fileName := filepath[len(filepath)-1]
-
-		tmpPath = path.Join(os.TempDir(), fileName)
-		s3client, err := s3tool.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, s.spec.S3Storage.Provider)
-		if err == nil {
-			objectKey := GetObjectPath(fileName, s.spec.S3Storage.Subfolder)
-			err = s3client.Download(
-				s.spec.S3Storage.Bucket,
-				objectKey,
-				tmpPath,
-			)
-
-			// 缓存不存在
-			if err != nil {
-				err := httpclient.Download(tool.Download, tmpPath)
-				if err != nil {
-					return fmt.Errorf("download package %s error: %v", tool.Download, err)
-				}
-				s3client.Upload(
-					s.spec.S3Storage.Bucket,
-					tmpPath,
-					objectKey,
-				)
-				log.Infof("Package loaded from url: %s", tool.Download)
-			}
-		} else {
-			err := httpclient.Download(tool.Download, tmpPath)
-			if err != nil {
-				return err
-			}
+	// 如果应用有配置下载路径或其它来源
+	if tool.Download != "" || tool.Source.Kind != "" {
+		installer, err := NewInstaller(tool.Source.Kind)
+		if err != nil {
+			return fmt.Errorf("install %s %s: %v", tool.Name, tool.Version, err)
+		}
+		downloadStart := time.Now()
+		tmpPath, err = installer.Fetch(ctx, tool, s.spec.S3Storage, s.infof)
+		if err != nil {
+			return err
 		}
+		s.infof("tool=%s version=%s fetched via %s in %.2f seconds.", tool.Name, tool.Version, installer.Kind(), time.Since(downloadStart).Seconds())
 	}
 
 	for j, command := range tool.Scripts {
-		realCommand := strings.ReplaceAll(command, config.FilepathParam, tmpPath)
+		realCommand := strings.ReplaceAll(command, config.FilepathParam, shell.quotePath(tmpPath))
+		if shell.wrapLine != nil {
+			realCommand = shell.wrapLine(realCommand)
+		}
 		tool.Scripts[j] = realCommand
 	}
 
@@ -127,12 +145,12 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 		scripts = append(scripts, disProxyScript)
 	}
 	uid, _ := uuid.NewUUID()
-	file := filepath.Join(os.TempDir(), fmt.Sprintf("install_script_%d.sh", uid.ID()))
+	file := filepath.Join(os.TempDir(), fmt.Sprintf("install_script_%d%s", uid.ID(), shell.ext))
 	if err := ioutil.WriteFile(file, []byte(strings.Join(scripts, "\n")), 0700); err != nil {
 		return fmt.Errorf("write script file error: %v", err)
 	}
 
-	cmd := exec.Command("/bin/bash", file)
+	cmd := exec.Command(shell.command, shell.args(file)...)
 
 	cmdOutReader, err := cmd.StdoutPipe()
 	if err != nil {
@@ -142,7 +160,7 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 	outScanner := bufio.NewScanner(cmdOutReader)
 	go func() {
 		for outScanner.Scan() {
-			fmt.Printf("%s   %s\n", time.Now().Format(setting.WorkflowTimeFormat), outScanner.Text())
+			s.logLine(tool, outScanner.Text(), false)
 		}
 	}()
 
@@ -154,7 +172,7 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 	errScanner := bufio.NewScanner(cmdErrReader)
 	go func() {
 		for errScanner.Scan() {
-			fmt.Printf("%s   %s\n", time.Now().Format(setting.WorkflowTimeFormat), errScanner.Text())
+			s.logLine(tool, errScanner.Text(), true)
 		}
 	}()
 
@@ -168,6 +186,120 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 	return nil
 }
 
+// logLine forwards a scanned line of install-script output through the
+// structured JobLogger, prefixed with which tool produced it, or falls
+// back to the plain timestamped stdout format when no logger is set.
+func (s *ToolInstallStep) logLine(tool *step.Tool, line string, isErr bool) {
+	if s.logger == nil {
+		fmt.Printf("%s   %s\n", time.Now().Format(setting.WorkflowTimeFormat), line)
+		return
+	}
+
+	message := fmt.Sprintf("tool=%s version=%s %s", tool.Name, tool.Version, line)
+	if isErr {
+		s.logger.Errorf(message)
+	} else {
+		s.logger.Infof(message)
+	}
+}
+
+// verifyToolIntegrity enforces the digest and signature declared on tool
+// against the file at path, trusting the digest rather than the transport
+// it arrived over. It is a no-op when no digest or signature is declared.
+func verifyToolIntegrity(path string, tool *step.Tool) error {
+	if tool.Size > 0 || tool.SHA256 != "" || tool.SHA512 != "" {
+		if err := verifyToolDigest(path, tool); err != nil {
+			return err
+		}
+	}
+
+	if tool.SignatureURL != "" && tool.PublicKey != "" {
+		if err := verifyToolSignature(path, tool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func verifyToolDigest(path string, tool *step.Tool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open downloaded file: %v", err)
+	}
+	defer f.Close()
+
+	sha256Hash := sha256.New()
+	sha512Hash := sha512.New()
+	size, err := io.Copy(io.MultiWriter(sha256Hash, sha512Hash), f)
+	if err != nil {
+		return fmt.Errorf("hash downloaded file: %v", err)
+	}
+
+	if tool.Size > 0 && size != tool.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", tool.Size, size)
+	}
+
+	if tool.SHA256 != "" {
+		if actual := hex.EncodeToString(sha256Hash.Sum(nil)); !strings.EqualFold(actual, tool.SHA256) {
+			return fmt.Errorf("sha256 mismatch: expected %s, got %s", tool.SHA256, actual)
+		}
+	}
+
+	if tool.SHA512 != "" {
+		if actual := hex.EncodeToString(sha512Hash.Sum(nil)); !strings.EqualFold(actual, tool.SHA512) {
+			return fmt.Errorf("sha512 mismatch: expected %s, got %s", tool.SHA512, actual)
+		}
+	}
+
+	return nil
+}
+
+// verifyToolSignature fetches the detached signature referenced by
+// tool.SignatureURL and verifies it against path using tool.PublicKey, a
+// PEM-encoded RSA public key.
+func verifyToolSignature(path string, tool *step.Tool) error {
+	block, _ := pem.Decode([]byte(tool.PublicKey))
+	if block == nil {
+		return fmt.Errorf("invalid public key: not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	sigPath := path + ".sig"
+	defer os.Remove(sigPath)
+	if err := httpclient.Download(tool.SignatureURL, sigPath); err != nil {
+		return fmt.Errorf("download signature: %v", err)
+	}
+	signature, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("read signature: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open downloaded file: %v", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("hash downloaded file: %v", err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(rsaPub, crypto.SHA256, hash.Sum(nil), signature); err != nil {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	return nil
+}
+
 func Environs(envs []string) []string {
 	resp := []string{}
 	for _, val := range envs {
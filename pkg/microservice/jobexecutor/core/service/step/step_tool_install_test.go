@@ -0,0 +1,110 @@
+package step
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koderover/zadig/v2/pkg/types/step"
+)
+
+func writeTempFile(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tool-artifact")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	return path
+}
+
+func TestVerifyToolDigest(t *testing.T) {
+	content := []byte("tool-payload")
+	sha256Sum := sha256.Sum256(content)
+	sha256Hex := hex.EncodeToString(sha256Sum[:])
+
+	cases := []struct {
+		name    string
+		tool    *step.Tool
+		wantErr bool
+	}{
+		{"no digest declared", &step.Tool{}, false},
+		{"matching sha256", &step.Tool{SHA256: sha256Hex}, false},
+		{"mismatched sha256", &step.Tool{SHA256: "deadbeef"}, true},
+		{"matching size", &step.Tool{Size: int64(len(content))}, false},
+		{"mismatched size", &step.Tool{Size: int64(len(content)) + 1}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := writeTempFile(t, content)
+			err := verifyToolDigest(path, c.tool)
+			if c.wantErr && err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyToolSignature(t *testing.T) {
+	content := []byte("tool-payload")
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	hashed := sha256.Sum256(content)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("sign payload: %v", err)
+	}
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad.sig" {
+			w.Write([]byte("not-a-signature"))
+			return
+		}
+		w.Write(signature)
+	}))
+	defer srv.Close()
+
+	path := writeTempFile(t, content)
+
+	t.Run("valid signature", func(t *testing.T) {
+		tool := &step.Tool{SignatureURL: srv.URL + "/good.sig", PublicKey: pubPEM}
+		if err := verifyToolSignature(path, tool); err != nil {
+			t.Fatalf("expected signature to verify, got %v", err)
+		}
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		tool := &step.Tool{SignatureURL: srv.URL + "/bad.sig", PublicKey: pubPEM}
+		if err := verifyToolSignature(path, tool); err == nil {
+			t.Fatalf("expected signature verification to fail")
+		}
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		tool := &step.Tool{SignatureURL: srv.URL + "/good.sig", PublicKey: "not-pem"}
+		if err := verifyToolSignature(path, tool); err == nil {
+			t.Fatalf("expected error for invalid public key")
+		}
+	})
+}
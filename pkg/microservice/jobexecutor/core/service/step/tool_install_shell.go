@@ -0,0 +1,158 @@
+package step
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/koderover/zadig/v2/pkg/types/step"
+)
+
+// shellProfile captures everything runIntallationScripts needs to know to
+// drive a given interpreter: how to invoke it, what file extension its
+// scripts expect, the "fail fast and echo commands" header equivalent,
+// and how to quote a filesystem path substituted into a script.
+type shellProfile struct {
+	shell       string
+	command     string
+	args        func(scriptFile string) []string
+	ext         string
+	header      string
+	quotePath   func(path string) string
+	pathListSep string
+	// wrapLine adapts a single tool.Scripts entry so a failure actually aborts
+	// the run. bash/sh rely on "set -e" and PowerShell on
+	// $ErrorActionPreference, but cmd.exe keeps executing a .bat after a
+	// failing line regardless of any header, so it needs an explicit
+	// errorlevel check appended to every line. Nil means no wrapping is
+	// needed.
+	wrapLine func(line string) string
+}
+
+var shellProfiles = map[string]shellProfile{
+	step.ShellBash: {
+		shell:       step.ShellBash,
+		command:     "bash",
+		args:        func(f string) []string { return []string{f} },
+		ext:         ".sh",
+		header:      "set -ex",
+		quotePath:   shellQuotePosix,
+		pathListSep: ":",
+	},
+	step.ShellSh: {
+		shell:       step.ShellSh,
+		command:     "sh",
+		args:        func(f string) []string { return []string{f} },
+		ext:         ".sh",
+		header:      "set -ex",
+		quotePath:   shellQuotePosix,
+		pathListSep: ":",
+	},
+	step.ShellPowerShell: {
+		shell:       step.ShellPowerShell,
+		command:     "powershell",
+		args:        func(f string) []string { return []string{"-NoProfile", "-NonInteractive", "-File", f} },
+		ext:         ".ps1",
+		header:      "$ErrorActionPreference = 'Stop'; Set-PSDebug -Trace 1",
+		quotePath:   shellQuotePowerShell,
+		pathListSep: ";",
+	},
+	step.ShellPwsh: {
+		shell:       step.ShellPwsh,
+		command:     "pwsh",
+		args:        func(f string) []string { return []string{"-NoProfile", "-NonInteractive", "-File", f} },
+		ext:         ".ps1",
+		header:      "$ErrorActionPreference = 'Stop'; Set-PSDebug -Trace 1",
+		quotePath:   shellQuotePowerShell,
+		pathListSep: ";",
+	},
+	step.ShellCmd: {
+		shell:       step.ShellCmd,
+		command:     "cmd",
+		args:        func(f string) []string { return []string{"/D", "/E:ON", "/V:OFF", "/C", f} },
+		ext:         ".bat",
+		header:      "@echo on",
+		quotePath:   shellQuoteCmd,
+		pathListSep: ";",
+		wrapLine:    wrapCmdLine,
+	},
+}
+
+// wrapCmdLine appends an errorlevel check after every line of a script
+// entry so a failing command aborts the batch file instead of letting a
+// later line's exit code mask it. A single tool.Scripts entry may itself
+// contain several newline-separated statements (e.g. a YAML block
+// scalar), so the check must be inserted after each one, not just after
+// the entry as a whole.
+func wrapCmdLine(line string) string {
+	lines := strings.Split(line, "\n")
+	for i, l := range lines {
+		trimmed := strings.TrimRight(l, "\r")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		lines[i] = trimmed + "\r\nif errorlevel 1 exit /b %errorlevel%"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolveShell honors an explicit tool.Shell, otherwise auto-detects a
+// usable interpreter for the host: PowerShell family falling back to cmd
+// on Windows, bash falling back to sh everywhere else.
+func resolveShell(tool *step.Tool) (shellProfile, error) {
+	if tool.Shell != "" {
+		profile, ok := shellProfiles[tool.Shell]
+		if !ok {
+			return shellProfile{}, fmt.Errorf("unsupported shell %q", tool.Shell)
+		}
+		return profile, nil
+	}
+
+	var candidates []string
+	if runtime.GOOS == "windows" {
+		candidates = []string{step.ShellPwsh, step.ShellPowerShell, step.ShellCmd}
+	} else {
+		candidates = []string{step.ShellBash, step.ShellSh}
+	}
+
+	for _, name := range candidates {
+		if _, err := exec.LookPath(shellProfiles[name].command); err == nil {
+			return shellProfiles[name], nil
+		}
+	}
+
+	return shellProfile{}, fmt.Errorf("no usable shell found among %v", candidates)
+}
+
+func shellQuotePosix(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+func shellQuotePowerShell(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", "''") + "'"
+}
+
+func shellQuoteCmd(path string) string {
+	return `"` + strings.ReplaceAll(path, `"`, `""`) + `"`
+}
+
+// environsForShell applies the same substitutions as Environs, then
+// rewrites PATH-style values to the target shell's path-list separator
+// so a PATH entry written with ":" still works when the runner is
+// executing under cmd/PowerShell on Windows.
+func environsForShell(envs []string, profile shellProfile) []string {
+	resp := Environs(envs)
+	if profile.pathListSep == ":" {
+		return resp
+	}
+
+	for i, env := range resp {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.EqualFold(key, "PATH") {
+			continue
+		}
+		resp[i] = key + "=" + strings.ReplaceAll(value, ":", profile.pathListSep)
+	}
+	return resp
+}
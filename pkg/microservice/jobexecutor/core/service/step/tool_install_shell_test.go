@@ -0,0 +1,95 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/koderover/zadig/v2/pkg/types/step"
+)
+
+func TestShellQuotePosix(t *testing.T) {
+	cases := map[string]string{
+		"/tmp/plain":    "'/tmp/plain'",
+		"/tmp/o'reilly": `'/tmp/o'\''reilly'`,
+		"":              "''",
+	}
+	for in, want := range cases {
+		if got := shellQuotePosix(in); got != want {
+			t.Errorf("shellQuotePosix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestShellQuotePowerShell(t *testing.T) {
+	cases := map[string]string{
+		`C:\tools\x`:   `'C:\tools\x'`,
+		`C:\it's\here`: `'C:\it''s\here'`,
+	}
+	for in, want := range cases {
+		if got := shellQuotePowerShell(in); got != want {
+			t.Errorf("shellQuotePowerShell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestShellQuoteCmd(t *testing.T) {
+	cases := map[string]string{
+		`C:\tools\x`:     `"C:\tools\x"`,
+		`C:\has"quote\x`: `"C:\has""quote\x"`,
+	}
+	for in, want := range cases {
+		if got := shellQuoteCmd(in); got != want {
+			t.Errorf("shellQuoteCmd(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWrapCmdLine(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "blank line untouched",
+			in:   "",
+			want: "",
+		},
+		{
+			name: "single statement",
+			in:   "curl -o out.zip https://example.com/tool.zip",
+			want: "curl -o out.zip https://example.com/tool.zip\r\nif errorlevel 1 exit /b %errorlevel%",
+		},
+		{
+			name: "multiple statements in one entry get a check each",
+			in:   "curl -o out.zip https://example.com/tool.zip\nunzip out.zip\n\nmove out\\bin.exe C:\\bin\\bin.exe",
+			want: "curl -o out.zip https://example.com/tool.zip\r\nif errorlevel 1 exit /b %errorlevel%\n" +
+				"unzip out.zip\r\nif errorlevel 1 exit /b %errorlevel%\n\n" +
+				"move out\\bin.exe C:\\bin\\bin.exe\r\nif errorlevel 1 exit /b %errorlevel%",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := wrapCmdLine(c.in); got != c.want {
+				t.Errorf("wrapCmdLine(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnvironsForShellRewritesPathSeparator(t *testing.T) {
+	envs := []string{"PATH=/usr/bin:/tool/bin", "OTHER=a:b"}
+
+	cmdResult := environsForShell(envs, shellProfiles[step.ShellCmd])
+	if cmdResult[0] != "PATH=/usr/bin;/tool/bin" {
+		t.Errorf("PATH not rewritten for cmd: got %q", cmdResult[0])
+	}
+	if cmdResult[1] != "OTHER=a:b" {
+		t.Errorf("non-PATH var should be untouched: got %q", cmdResult[1])
+	}
+
+	posixResult := environsForShell(envs, shellProfiles[step.ShellBash])
+	if posixResult[0] != "PATH=/usr/bin:/tool/bin" {
+		t.Errorf("PATH should be untouched for bash: got %q", posixResult[0])
+	}
+}
@@ -0,0 +1,298 @@
+package step
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/koderover/zadig/v2/pkg/microservice/reaper/config"
+	"github.com/koderover/zadig/v2/pkg/tool/httpclient"
+	"github.com/koderover/zadig/v2/pkg/tool/log"
+	s3tool "github.com/koderover/zadig/v2/pkg/tool/s3"
+	"github.com/koderover/zadig/v2/pkg/types/step"
+)
+
+// Installer fetches a tool onto the local filesystem. Fetch returns the
+// local path (a file, or the checkout directory for git) substituted into
+// tool.Scripts via config.FilepathParam,
+// or "" when the tool does not need a local artifact (e.g. system
+// installs that act directly on the host). infof reports progress (such
+// as cache hit/miss) through whatever logger the caller is using.
+type Installer interface {
+	Kind() string
+	Fetch(ctx context.Context, tool *step.Tool, s3Storage step.S3Storage, infof func(string, ...interface{})) (string, error)
+}
+
+// NewInstaller resolves the Installer for kind, defaulting to the url
+// installer for backward compatibility with Download-only specs.
+func NewInstaller(kind string) (Installer, error) {
+	switch kind {
+	case "", step.SourceKindURL:
+		return &urlInstaller{}, nil
+	case step.SourceKindOCI:
+		return &ociInstaller{}, nil
+	case step.SourceKindGit:
+		return &gitInstaller{}, nil
+	case step.SourceKindSystem:
+		return &systemInstaller{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tool source kind %q", kind)
+	}
+}
+
+func newS3Client(s3Storage step.S3Storage) (*s3tool.Client, error) {
+	return s3tool.NewClient(s3Storage.Endpoint, s3Storage.Ak, s3Storage.Sk, s3Storage.Region, s3Storage.Insecure, s3Storage.Provider)
+}
+
+// toolCacheObjectKey builds the S3 object key an installer should use to
+// cache its fetched artifact, namespaced by kind + name + version so
+// different installers and tool versions never collide.
+func toolCacheObjectKey(kind, name, version, qualifier string) string {
+	subfolder := fmt.Sprintf("%s/%s-%s-v%s", config.ConstructCachePath, kind, name, version)
+	if qualifier != "" {
+		name = fmt.Sprintf("%s-%s", name, qualifier)
+	}
+	return GetObjectPath(name, subfolder)
+}
+
+// urlInstaller downloads tool.Download over HTTP(S), the behavior this
+// step has always had.
+type urlInstaller struct{}
+
+func (i *urlInstaller) Kind() string { return step.SourceKindURL }
+
+func (i *urlInstaller) Fetch(ctx context.Context, tool *step.Tool, s3Storage step.S3Storage, infof func(string, ...interface{})) (string, error) {
+	if tool.Download == "" {
+		return "", nil
+	}
+
+	parts := strings.Split(tool.Download, "/")
+	fileName := parts[len(parts)-1]
+	tmpPath := path.Join(os.TempDir(), fileName)
+	objectKey := toolCacheObjectKey(i.Kind(), tool.Name, tool.Version, tool.SHA256)
+
+	s3client, err := newS3Client(s3Storage)
+	if err == nil {
+		if dlErr := s3client.Download(s3Storage.Bucket, objectKey, tmpPath); dlErr == nil {
+			// 缓存命中，重新校验摘要，防止被污染的缓存静默生效
+			if verifyErr := verifyToolIntegrity(tmpPath, tool); verifyErr == nil {
+				infof("cache hit for %s %s, skipping download", tool.Name, tool.Version)
+				return tmpPath, nil
+			} else {
+				log.Warnf("cached package for %s %s failed integrity check, refetching: %v", tool.Name, tool.Version, verifyErr)
+			}
+			os.Remove(tmpPath)
+		}
+	}
+
+	infof("cache miss for %s %s, downloading from %s", tool.Name, tool.Version, tool.Download)
+	if dlErr := httpclient.Download(tool.Download, tmpPath); dlErr != nil {
+		return "", fmt.Errorf("download package %s error: %v", tool.Download, dlErr)
+	}
+	if verifyErr := verifyToolIntegrity(tmpPath, tool); verifyErr != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("verify package %s error: %v", tool.Download, verifyErr)
+	}
+	if s3client != nil {
+		s3client.Upload(s3Storage.Bucket, tmpPath, objectKey)
+	}
+	return tmpPath, nil
+}
+
+// ociInstaller pulls an OCI artifact using the ORAS CLI, which already
+// implements manifest and layer resolution against any OCI-compliant
+// registry, and returns the path of the layer named by Source.LayerPath.
+type ociInstaller struct{}
+
+func (i *ociInstaller) Kind() string { return step.SourceKindOCI }
+
+func (i *ociInstaller) Fetch(ctx context.Context, tool *step.Tool, s3Storage step.S3Storage, infof func(string, ...interface{})) (string, error) {
+	ref := fmt.Sprintf("%s/%s:%s", tool.Source.Registry, tool.Source.Repository, tool.Source.Tag)
+	// Prefer a declared digest as the cache qualifier so republishing a
+	// tool under the same mutable tag invalidates the cache; fall back to
+	// the tag only when no digest is declared.
+	qualifier := tool.SHA256
+	if qualifier == "" {
+		qualifier = tool.SHA512
+	}
+	if qualifier == "" {
+		qualifier = tool.Source.Tag
+	}
+	objectKey := toolCacheObjectKey(i.Kind(), tool.Name, tool.Version, qualifier)
+	tmpDir, err := os.MkdirTemp(os.TempDir(), fmt.Sprintf("%s-%s-", tool.Name, tool.Version))
+	if err != nil {
+		return "", fmt.Errorf("create temp dir for oci pull: %v", err)
+	}
+	// oras pull extracts every layer of the artifact into tmpDir using
+	// each layer's own annotated relative path, which may include
+	// subdirectories (e.g. "bin/helm") -- not just LayerPath's basename.
+	tmpPath := filepath.Join(tmpDir, filepath.FromSlash(tool.Source.LayerPath))
+
+	s3client, err := newS3Client(s3Storage)
+	if err == nil {
+		if dlErr := s3client.Download(s3Storage.Bucket, objectKey, tmpPath); dlErr == nil {
+			if verifyErr := verifyToolIntegrity(tmpPath, tool); verifyErr == nil {
+				infof("cache hit for %s %s, skipping oci pull", tool.Name, tool.Version)
+				return tmpPath, nil
+			}
+			log.Warnf("cached oci artifact for %s %s failed integrity check, refetching", tool.Name, tool.Version)
+			os.Remove(tmpPath)
+		}
+	}
+
+	infof("cache miss for %s %s, pulling oci artifact %s", tool.Name, tool.Version, ref)
+	cmd := exec.CommandContext(ctx, "oras", "pull", ref, "-o", tmpDir)
+	if out, runErr := cmd.CombinedOutput(); runErr != nil {
+		return "", fmt.Errorf("oras pull %s failed: %v, output: %s", ref, runErr, out)
+	}
+
+	if _, statErr := os.Stat(tmpPath); statErr != nil {
+		return "", fmt.Errorf("layer %s not found in pulled artifact %s: %v", tool.Source.LayerPath, ref, statErr)
+	}
+	if verifyErr := verifyToolIntegrity(tmpPath, tool); verifyErr != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("verify oci artifact %s error: %v", ref, verifyErr)
+	}
+	if s3client != nil {
+		s3client.Upload(s3Storage.Bucket, tmpPath, objectKey)
+	}
+	return tmpPath, nil
+}
+
+// gitInstaller shallow-clones a tool's repository at a ref so workflows
+// can depend on a tagged git tool without pre-packaging a tarball. The
+// checkout is cached in S3 as a tar.gz, the same way the url and oci
+// installers cache their fetched artifact, keyed by Kind + Name +
+// Version + ref.
+type gitInstaller struct{}
+
+func (i *gitInstaller) Kind() string { return step.SourceKindGit }
+
+func (i *gitInstaller) Fetch(ctx context.Context, tool *step.Tool, s3Storage step.S3Storage, infof func(string, ...interface{})) (string, error) {
+	repo := tool.Source.Repo
+	if repo == "" {
+		repo = tool.Download
+	}
+	ref := tool.Source.Ref
+	if ref == "" {
+		ref = tool.Version
+	}
+
+	tmpDir, err := os.MkdirTemp(os.TempDir(), fmt.Sprintf("%s-%s-", tool.Name, tool.Version))
+	if err != nil {
+		return "", fmt.Errorf("create temp dir for git clone: %v", err)
+	}
+
+	objectKey := toolCacheObjectKey(i.Kind(), tool.Name, tool.Version, ref)
+	s3client, err := newS3Client(s3Storage)
+	if err == nil {
+		tarPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s-checkout.tar.gz", tool.Name, tool.Version))
+		if dlErr := s3client.Download(s3Storage.Bucket, objectKey, tarPath); dlErr == nil {
+			if untarErr := untarDir(tarPath, tmpDir); untarErr == nil {
+				os.Remove(tarPath)
+				infof("cache hit for %s %s, skipping git clone", tool.Name, tool.Version)
+				return tmpDir, nil
+			} else {
+				log.Warnf("cached checkout for %s %s failed to extract, recloning: %v", tool.Name, tool.Version, untarErr)
+			}
+			os.Remove(tarPath)
+		}
+	}
+
+	infof("cache miss for %s %s, cloning %s at %s", tool.Name, tool.Version, repo, ref)
+	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--branch", ref, repo, tmpDir)
+	if out, runErr := cmd.CombinedOutput(); runErr != nil {
+		return "", fmt.Errorf("git clone %s@%s failed: %v, output: %s", repo, ref, runErr, out)
+	}
+
+	if s3client != nil {
+		if tarPath, tarErr := tarDir(tmpDir); tarErr == nil {
+			s3client.Upload(s3Storage.Bucket, tarPath, objectKey)
+			os.Remove(tarPath)
+		}
+	}
+
+	return tmpDir, nil
+}
+
+// tarDir compresses the contents of dir into a new temp .tar.gz file,
+// the same way reaper's TarCacheManager packages a directory for S3.
+func tarDir(dir string) (string, error) {
+	tmpFile, err := os.CreateTemp(os.TempDir(), "*-tool-checkout.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("create temp archive: %v", err)
+	}
+	tmpFile.Close()
+
+	cmd := exec.Command("tar", "czf", tmpFile.Name(), "-C", dir, ".")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("tar %s failed: %v, output: %s", dir, err, out)
+	}
+	return tmpFile.Name(), nil
+}
+
+// untarDir extracts the tar.gz at archivePath into dir, creating dir if
+// it does not already exist.
+func untarDir(archivePath, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create dir %s: %v", dir, err)
+	}
+	cmd := exec.Command("tar", "xzf", archivePath, "-C", dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("untar %s failed: %v, output: %s", archivePath, err, out)
+	}
+	return nil
+}
+
+// systemInstaller delegates to the host's package manager, picking it
+// based on the detected OS the way an operator would by hand.
+type systemInstaller struct{}
+
+func (i *systemInstaller) Kind() string { return step.SourceKindSystem }
+
+func (i *systemInstaller) Fetch(ctx context.Context, tool *step.Tool, s3Storage step.S3Storage, infof func(string, ...interface{})) (string, error) {
+	pkg := tool.Source.Package
+	if pkg == "" {
+		pkg = tool.Name
+	}
+
+	name, args, err := systemPackageManagerCommand(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	infof("installing %s via %s", pkg, name)
+	cmd := exec.CommandContext(ctx, name, args...)
+	if out, runErr := cmd.CombinedOutput(); runErr != nil {
+		return "", fmt.Errorf("%s install %s failed: %v, output: %s", name, pkg, runErr, out)
+	}
+
+	return "", nil
+}
+
+func systemPackageManagerCommand(pkg string) (string, []string, error) {
+	if runtime.GOOS == "darwin" {
+		return "brew", []string{"install", pkg}, nil
+	}
+
+	for _, candidate := range []struct {
+		bin  string
+		args []string
+	}{
+		{"apt-get", []string{"install", "-y", pkg}},
+		{"yum", []string{"install", "-y", pkg}},
+		{"apk", []string{"add", pkg}},
+	} {
+		if _, err := exec.LookPath(candidate.bin); err == nil {
+			return candidate.bin, candidate.args, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no supported package manager found for %q on %s", pkg, runtime.GOOS)
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -128,6 +129,30 @@ func (c *Client) GetComponentMeasures(component, branch string) (*MeasuresCompon
 	return resp, nil
 }
 
+// GetPullRequestMeasures is the pull-request analog of GetComponentMeasures:
+// it scopes the measures to the analysis run against prKey instead of a
+// branch, so results are attributed to the PR's own dashboard.
+//
+// This only covers the Sonar-side API; wiring a PR-triggered workflow to
+// call this and post the result back to the SCM as a comment/status is
+// left to the job/workflow layer that consumes sonar.Client and is not
+// part of this change.
+func (c *Client) GetPullRequestMeasures(component, prKey string) (*MeasuresComponentResponse, error) {
+	resp := &MeasuresComponentResponse{}
+	rfs := []httpclient.RequestFunc{
+		httpclient.SetQueryParam("component", component),
+		httpclient.SetQueryParam("metricKeys", "ncloc,bugs,vulnerabilities,code_smells,coverage"),
+		httpclient.SetQueryParam("pullRequest", prKey),
+		httpclient.SetResult(resp),
+	}
+
+	url := "/api/measures/component"
+	if _, err := c.Client.Get(url, rfs...); err != nil {
+		return nil, fmt.Errorf("failed to search sonar component measures, component: %s, pull request: %s, error: %v", component, prKey, err)
+	}
+	return resp, nil
+}
+
 type QualityGateStatus string
 
 const (
@@ -167,6 +192,235 @@ func (c *Client) GetQualityGateInfo(analysisID string) (*ProjectInfo, error) {
 	return res, nil
 }
 
+// GetPullRequestQualityGate fetches the quality-gate status for a
+// pull-request analysis. Unlike GetQualityGateInfo, which keys off a
+// completed analysisId, this keys off component + pullRequest so it can
+// be polled as soon as the PR-scoped analysis is visible to the API.
+//
+// Posting the resulting table back to the SCM as a PR comment/status is
+// a job/workflow-layer concern and is not wired up here; see
+// GetPullRequestMeasures for the same caveat.
+func (c *Client) GetPullRequestQualityGate(component, prKey string) (*ProjectInfo, error) {
+	url := "/api/qualitygates/project_status"
+	res := &ProjectInfo{}
+	if _, err := c.Client.Get(url,
+		httpclient.SetQueryParam("projectKey", component),
+		httpclient.SetQueryParam("pullRequest", prKey),
+		httpclient.SetResult(res),
+	); err != nil {
+		return nil, fmt.Errorf("get sonar quality gate for component: %s, pull request: %s error: %v", component, prKey, err)
+	}
+	return res, nil
+}
+
+// FormatPullRequestComment renders the result of GetPullRequestMeasures and
+// GetPullRequestQualityGate into the markdown body a PR comment would use.
+// This is as far as sonar.Client goes: it has no business knowing about
+// GitHub/GitLab/Gerrit, so posting the returned string to the SCM as a
+// comment/status is still a job/workflow-layer concern, same as
+// GetPullRequestMeasures and GetPullRequestQualityGate themselves.
+func FormatPullRequestComment(measures *MeasuresComponentResponse, gate *ProjectInfo) string {
+	var b strings.Builder
+	b.WriteString("**Sonar Analysis Result**\n\n")
+	b.WriteString(fmt.Sprintf("Quality Gate: **%s**\n\n", gate.ProjectStatus.Status))
+
+	if len(measures.Component.Measures) > 0 {
+		b.WriteString("| Metric | Value |\n")
+		b.WriteString("| --- | --- |\n")
+		for _, m := range measures.Component.Measures {
+			b.WriteString(fmt.Sprintf("| %s | %s |\n", m.Metric, m.Value))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(gate.ProjectStatus.Conditions) > 0 {
+		b.WriteString("| Condition | Status | Actual | Threshold |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, cond := range gate.ProjectStatus.Conditions {
+			b.WriteString(fmt.Sprintf("| %s | %s | %s | %s %s |\n", cond.MetricKey, cond.Status, cond.ActualValue, cond.Comparator, cond.ErrorThreshold))
+		}
+	}
+
+	return b.String()
+}
+
+// issuesPageSize is the page size requested from /api/issues/search and
+// /api/hotspots/search; both endpoints cap ps at 500.
+const issuesPageSize = 500
+
+type Issue struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Component string `json:"component"`
+	Line      int    `json:"line"`
+	Message   string `json:"message"`
+	Effort    string `json:"effort"`
+}
+
+type issuesSearchResponse struct {
+	Total  int     `json:"total"`
+	P      int     `json:"p"`
+	Ps     int     `json:"ps"`
+	Issues []Issue `json:"issues"`
+}
+
+// IssueSearchFilters narrows a SearchIssues call the way the Sonar UI's
+// issue list does: by severity, by issue type, by resolution and by leak
+// period. Resolved and SinceLeakPeriod are always sent as given, so the
+// zero value asks for unresolved issues from all periods.
+type IssueSearchFilters struct {
+	Severities      []string
+	Types           []string
+	Resolved        bool
+	SinceLeakPeriod bool
+}
+
+// SearchIssues wraps /api/issues/search, paginating until every matching
+// issue has been collected.
+func (c *Client) SearchIssues(component, branch string, filters IssueSearchFilters) ([]Issue, error) {
+	rfs := []httpclient.RequestFunc{
+		httpclient.SetQueryParam("componentKeys", component),
+		httpclient.SetQueryParam("resolved", fmt.Sprintf("%t", filters.Resolved)),
+		httpclient.SetQueryParam("sinceLeakPeriod", fmt.Sprintf("%t", filters.SinceLeakPeriod)),
+		httpclient.SetQueryParam("ps", fmt.Sprintf("%d", issuesPageSize)),
+	}
+	if branch != "" {
+		rfs = append(rfs, httpclient.SetQueryParam("branch", branch))
+	}
+	if len(filters.Severities) > 0 {
+		rfs = append(rfs, httpclient.SetQueryParam("severities", strings.Join(filters.Severities, ",")))
+	}
+	if len(filters.Types) > 0 {
+		rfs = append(rfs, httpclient.SetQueryParam("types", strings.Join(filters.Types, ",")))
+	}
+
+	var issues []Issue
+	for page := 1; ; page++ {
+		resp := &issuesSearchResponse{}
+		pageRfs := append(append([]httpclient.RequestFunc{}, rfs...), httpclient.SetQueryParam("p", fmt.Sprintf("%d", page)), httpclient.SetResult(resp))
+		if _, err := c.Client.Get("/api/issues/search", pageRfs...); err != nil {
+			return nil, fmt.Errorf("failed to search sonar issues, component: %s, branch: %s, error: %v", component, branch, err)
+		}
+		issues = append(issues, resp.Issues...)
+		if len(issues) >= resp.Total || len(resp.Issues) == 0 {
+			break
+		}
+	}
+	return issues, nil
+}
+
+type Hotspot struct {
+	Key                      string `json:"key"`
+	Component                string `json:"component"`
+	SecurityCategory         string `json:"securityCategory"`
+	VulnerabilityProbability string `json:"vulnerabilityProbability"`
+	Status                   string `json:"status"`
+	Line                     int    `json:"line"`
+	Message                  string `json:"message"`
+}
+
+type hotspotsSearchResponse struct {
+	Paging struct {
+		Total     int `json:"total"`
+		PageIndex int `json:"pageIndex"`
+		PageSize  int `json:"pageSize"`
+	} `json:"paging"`
+	Hotspots []Hotspot `json:"hotspots"`
+}
+
+// SearchHotspots wraps /api/hotspots/search, paginating until every
+// matching hotspot has been collected.
+func (c *Client) SearchHotspots(component, branch string) ([]Hotspot, error) {
+	rfs := []httpclient.RequestFunc{
+		httpclient.SetQueryParam("projectKey", component),
+		httpclient.SetQueryParam("ps", fmt.Sprintf("%d", issuesPageSize)),
+	}
+	if branch != "" {
+		rfs = append(rfs, httpclient.SetQueryParam("branch", branch))
+	}
+
+	var hotspots []Hotspot
+	for page := 1; ; page++ {
+		resp := &hotspotsSearchResponse{}
+		pageRfs := append(append([]httpclient.RequestFunc{}, rfs...), httpclient.SetQueryParam("p", fmt.Sprintf("%d", page)), httpclient.SetResult(resp))
+		if _, err := c.Client.Get("/api/hotspots/search", pageRfs...); err != nil {
+			return nil, fmt.Errorf("failed to search sonar hotspots, component: %s, branch: %s, error: %v", component, branch, err)
+		}
+		hotspots = append(hotspots, resp.Hotspots...)
+		if len(hotspots) >= resp.Paging.Total || len(resp.Hotspots) == 0 {
+			break
+		}
+	}
+	return hotspots, nil
+}
+
+// maxPrintedIssues caps PrintSonarIssuesTable/VMPrintSonarIssuesTable
+// output so a gate failing on hundreds of issues doesn't flood the job
+// log; issues are ranked by severity first.
+const maxPrintedIssues = 20
+
+var issueSeverityRank = map[string]int{
+	"BLOCKER":  0,
+	"CRITICAL": 1,
+	"MAJOR":    2,
+	"MINOR":    3,
+	"INFO":     4,
+}
+
+// unknownSeverityRank sorts an issue with an unrecognized or empty
+// Severity after every known severity, including INFO, instead of
+// defaulting to the map's zero value (which would outrank BLOCKER).
+const unknownSeverityRank = 5
+
+func severityRank(severity string) int {
+	if rank, ok := issueSeverityRank[severity]; ok {
+		return rank
+	}
+	return unknownSeverityRank
+}
+
+func topIssuesBySeverity(issues []Issue) []Issue {
+	sorted := make([]Issue, len(issues))
+	copy(sorted, issues)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return severityRank(sorted[i].Severity) < severityRank(sorted[j].Severity)
+	})
+	if len(sorted) > maxPrintedIssues {
+		sorted = sorted[:maxPrintedIssues]
+	}
+	return sorted
+}
+
+func PrintSonarIssuesTable(issues []Issue) {
+	top := topIssuesBySeverity(issues)
+	log.Infof("")
+	log.Infof("%-10s|%-40s|%-8s|%-60s|", "Severity", "Rule", "Line", "Message")
+	for _, issue := range top {
+		log.Infof("%-10s|%-40s|%-8d|%-60s|", issue.Severity, issue.Rule, issue.Line, issue.Message)
+	}
+	if len(issues) > len(top) {
+		log.Infof("... %d more issues not shown", len(issues)-len(top))
+	}
+	log.Infof("")
+}
+
+// VMPrintSonarIssuesTable mirrors PrintSonarIssuesTable for VM-runner
+// jobs. vmlog.JobLogger.Infof takes ...interface{} and joins them with
+// fmt.Sprint rather than treating the first argument as a format string,
+// so each line must be formatted with fmt.Sprintf before being logged.
+func VMPrintSonarIssuesTable(issues []Issue, logger *vmlog.JobLogger) {
+	top := topIssuesBySeverity(issues)
+	logger.Infof("")
+	logger.Infof(fmt.Sprintf("%-10s|%-40s|%-8s|%-60s|", "Severity", "Rule", "Line", "Message"))
+	for _, issue := range top {
+		logger.Infof(fmt.Sprintf("%-10s|%-40s|%-8d|%-60s|", issue.Severity, issue.Rule, issue.Line, issue.Message))
+	}
+	if len(issues) > len(top) {
+		logger.Infof(fmt.Sprintf("... %d more issues not shown", len(issues)-len(top)))
+	}
+	logger.Infof("")
+}
+
 func (c *Client) WaitForCETaskTobeDone(taskID string, timeout time.Duration) (string, error) {
 	timeouts := time.After(timeout)
 	ticker := time.NewTicker(5 * time.Second)
@@ -268,9 +522,38 @@ func GetSonarBranchFromConfig(config string) string {
 	return key
 }
 
+// GetSonarPullRequestKeyFromConfig returns the empty string if
+// sonar.pullrequest.key is empty or does not exist.
+func GetSonarPullRequestKeyFromConfig(config string) string {
+	return getSonarPropertiesValue(config, "sonar.pullrequest.key")
+}
+
+// GetSonarPullRequestBranchFromConfig returns the empty string if
+// sonar.pullrequest.branch is empty or does not exist.
+func GetSonarPullRequestBranchFromConfig(config string) string {
+	return getSonarPropertiesValue(config, "sonar.pullrequest.branch")
+}
+
+// GetSonarPullRequestBaseFromConfig returns the empty string if
+// sonar.pullrequest.base is empty or does not exist.
+func GetSonarPullRequestBaseFromConfig(config string) string {
+	return getSonarPropertiesValue(config, "sonar.pullrequest.base")
+}
+
+func getSonarPropertiesValue(config, key string) string {
+	v := viper.New()
+	v.SetConfigType("properties")
+	err := v.ReadConfig(strings.NewReader(config))
+	if err != nil {
+		return ""
+	}
+	value, _ := v.Get(key).(string)
+	return value
+}
+
 // GetSonarAddress return the corresponding project address according to projectKey
 // If the projectKey is empty or an error occurs, the original baseAddr is returned
-func GetSonarAddress(baseAddr, projectKey, branch string) (string, error) {
+func GetSonarAddress(baseAddr, projectKey, branch, pullRequest string) (string, error) {
 	if projectKey == "" {
 		return baseAddr, nil
 	}
@@ -281,7 +564,9 @@ func GetSonarAddress(baseAddr, projectKey, branch string) (string, error) {
 	u = u.JoinPath("dashboard")
 
 	values := url.Values{"id": {projectKey}}
-	if branch != "" {
+	if pullRequest != "" {
+		values["pullRequest"] = []string{pullRequest}
+	} else if branch != "" {
 		values["branch"] = []string{branch}
 	}
 
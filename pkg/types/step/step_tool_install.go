@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+// StepToolInstallSpec describes the set of tools a job needs installed
+// before its main scripts run.
+type StepToolInstallSpec struct {
+	Installs  []*Tool   `bson:"installs" yaml:"installs" json:"installs"`
+	S3Storage S3Storage `bson:"s3_storage" yaml:"s3_storage" json:"s3_storage"`
+}
+
+// S3Storage holds the connection info for the cache bucket used to avoid
+// re-downloading the same tool version on every job.
+type S3Storage struct {
+	Ak        string `bson:"ak" yaml:"ak" json:"ak"`
+	Sk        string `bson:"sk" yaml:"sk" json:"sk"`
+	Endpoint  string `bson:"endpoint" yaml:"endpoint" json:"endpoint"`
+	Bucket    string `bson:"bucket" yaml:"bucket" json:"bucket"`
+	Subfolder string `bson:"subfolder" yaml:"subfolder" json:"subfolder"`
+	Region    string `bson:"region" yaml:"region" json:"region"`
+	Provider  int8   `bson:"provider" yaml:"provider" json:"provider"`
+	Insecure  bool   `bson:"insecure" yaml:"insecure" json:"insecure"`
+}
+
+// Tool describes a single tool to install: where to fetch it from, what to
+// run once it is in place, and the environment the install scripts need.
+type Tool struct {
+	Name     string   `bson:"name" yaml:"name" json:"name"`
+	Version  string   `bson:"version" yaml:"version" json:"version"`
+	Download string   `bson:"download" yaml:"download" json:"download"`
+	Scripts  []string `bson:"scripts" yaml:"scripts" json:"scripts"`
+	Envs     []string `bson:"envs" yaml:"envs" json:"envs"`
+
+	// SHA256 and SHA512 are the expected hex-encoded digests of the
+	// downloaded file. Each one that is set must match, otherwise the
+	// download is rejected instead of being cached or executed.
+	SHA256 string `bson:"sha256,omitempty" yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	SHA512 string `bson:"sha512,omitempty" yaml:"sha512,omitempty" json:"sha512,omitempty"`
+	// Size is the expected size of the downloaded file in bytes. Ignored
+	// when zero.
+	Size int64 `bson:"size,omitempty" yaml:"size,omitempty" json:"size,omitempty"`
+
+	// SignatureURL points at a detached signature for Download. PublicKey
+	// is the PEM-encoded public key used to verify it. Both must be set
+	// for signature verification to take place.
+	SignatureURL string `bson:"signature_url,omitempty" yaml:"signature_url,omitempty" json:"signature_url,omitempty"`
+	PublicKey    string `bson:"public_key,omitempty" yaml:"public_key,omitempty" json:"public_key,omitempty"`
+
+	// Source selects how the tool is fetched. When empty, it defaults to
+	// SourceKindURL for backward compatibility with Download-only specs.
+	Source Source `bson:"source,omitempty" yaml:"source,omitempty" json:"source,omitempty"`
+
+	// Shell selects the interpreter that runs Scripts. When empty, it is
+	// auto-detected from runtime.GOOS and the runner's PATH.
+	Shell string `bson:"shell,omitempty" yaml:"shell,omitempty" json:"shell,omitempty"`
+}
+
+const (
+	ShellBash       = "bash"
+	ShellSh         = "sh"
+	ShellPowerShell = "powershell"
+	ShellPwsh       = "pwsh"
+	ShellCmd        = "cmd"
+)
+
+const (
+	SourceKindURL    = "url"
+	SourceKindOCI    = "oci"
+	SourceKindGit    = "git"
+	SourceKindSystem = "system"
+)
+
+// Source describes where and how to fetch a tool beyond a plain Download
+// URL. Its Kind selects the installer; the remaining fields are
+// interpreted differently by each installer.
+type Source struct {
+	// Kind is one of SourceKindURL, SourceKindOCI, SourceKindGit,
+	// SourceKindSystem.
+	Kind string `bson:"kind,omitempty" yaml:"kind,omitempty" json:"kind,omitempty"`
+
+	// Registry/Repository/Tag address an OCI artifact, e.g.
+	// registry.example.com/tools/helm:3.14.0. Every layer is extracted;
+	// LayerPath is the relative path of the extracted layer that the
+	// installer returns when an artifact carries more than one.
+	Registry   string `bson:"registry,omitempty" yaml:"registry,omitempty" json:"registry,omitempty"`
+	Repository string `bson:"repository,omitempty" yaml:"repository,omitempty" json:"repository,omitempty"`
+	Tag        string `bson:"tag,omitempty" yaml:"tag,omitempty" json:"tag,omitempty"`
+	LayerPath  string `bson:"layer_path,omitempty" yaml:"layer_path,omitempty" json:"layer_path,omitempty"`
+
+	// Repo/Ref address a git tool. Download is reused as the clone URL
+	// when Repo is empty.
+	Repo string `bson:"repo,omitempty" yaml:"repo,omitempty" json:"repo,omitempty"`
+	Ref  string `bson:"ref,omitempty" yaml:"ref,omitempty" json:"ref,omitempty"`
+
+	// Package is the OS package name to install via the system package
+	// manager, defaulting to Name when empty.
+	Package string `bson:"package,omitempty" yaml:"package,omitempty" json:"package,omitempty"`
+}